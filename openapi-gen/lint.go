@@ -0,0 +1,156 @@
+// Copyright 2018 The Nakama Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// swagger2MetaSchema and openapi3MetaSchema are the structural JSON Schemas
+// an input document must satisfy before code generation is allowed to run.
+// They are intentionally narrower than the full published meta-schemas:
+// they only assert the shape this generator actually reads (paths,
+// definitions/components, operationId, $ref strings), since that is what a
+// malformed spec silently breaks downstream.
+const swagger2MetaSchema = `{
+  "$schema": "http://json-schema.org/draft-04/schema#",
+  "type": "object",
+  "required": ["swagger", "paths"],
+  "properties": {
+    "swagger": { "type": "string", "enum": ["2.0"] },
+    "paths": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "object",
+        "additionalProperties": {
+          "type": "object",
+          "required": ["operationId"],
+          "properties": {
+            "operationId": { "type": "string" },
+            "parameters": {
+              "type": "array",
+              "items": {
+                "type": "object",
+                "required": ["name", "in"],
+                "properties": {
+                  "name": { "type": "string" },
+                  "in": { "type": "string", "enum": ["path", "query", "header", "body"] }
+                }
+              }
+            }
+          }
+        }
+      }
+    },
+    "definitions": {
+      "type": "object",
+      "additionalProperties": { "type": "object" }
+    }
+  }
+}`
+
+const openapi3MetaSchema = `{
+  "$schema": "http://json-schema.org/draft-04/schema#",
+  "type": "object",
+  "required": ["openapi", "paths"],
+  "properties": {
+    "openapi": { "type": "string" },
+    "paths": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "object",
+        "additionalProperties": {
+          "type": "object",
+          "required": ["operationId"],
+          "properties": {
+            "operationId": { "type": "string" }
+          }
+        }
+      }
+    },
+    "components": {
+      "type": "object",
+      "properties": {
+        "schemas": {
+          "type": "object",
+          "additionalProperties": { "type": "object" }
+        }
+      }
+    }
+  }
+}`
+
+// lintSpec validates the document at path against the meta-schema matching
+// its version and returns one human-readable violation per failure, each
+// prefixed with a best-effort "file:line" pointer.
+func lintSpec(path string) ([]string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %s", err)
+	}
+
+	metaSchema := swagger2MetaSchema
+	if isOpenAPI3(raw) {
+		metaSchema = openapi3MetaSchema
+	}
+
+	result, err := gojsonschema.Validate(
+		gojsonschema.NewStringLoader(metaSchema),
+		gojsonschema.NewBytesLoader(content),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var violations []string
+	for _, re := range result.Errors() {
+		line := locateLine(content, re.Field())
+		violations = append(violations, fmt.Sprintf("%s:%d: %s: %s", path, line, re.Field(), re.Description()))
+	}
+	return violations, nil
+}
+
+// locateLine makes a best-effort guess at which line a gojsonschema field
+// path (e.g. "paths./v2/account.get.operationId") lives on, by searching the
+// raw document for each path segment's key in order. It is a heuristic, not
+// an exact JSON-pointer-to-offset mapping, since encoding/json discards
+// token positions by the time a document has been unmarshalled.
+func locateLine(content []byte, field string) int {
+	if field == "(root)" {
+		return 1
+	}
+
+	offset := 0
+	for _, segment := range strings.Split(field, ".") {
+		key := []byte(`"` + segment + `"`)
+		idx := bytes.Index(content[offset:], key)
+		if idx < 0 {
+			break
+		}
+		offset += idx
+	}
+	return bytes.Count(content[:offset], []byte("\n")) + 1
+}
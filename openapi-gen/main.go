@@ -19,7 +19,6 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"strings"
 	"text/template"
@@ -36,6 +35,77 @@ namespace Nakama
 
     {{- range $defname, $definition := .Definitions }}
     {{- $classname := $defname | title }}
+    {{- if $definition.OneOfKinds }}
+
+    public enum {{ $classname }}Kind
+    {
+        {{- range $definition.OneOfKinds }}
+        {{ .Kind }},
+        {{- end }}
+    }
+
+    /// <summary>
+    /// {{ $definition.Description | stripNewlines }}
+    /// </summary>
+    public interface I{{ $classname }}
+    {
+        {{ $classname }}Kind Kind { get; }
+        {{- range $definition.OneOfKinds }}
+        I{{ .Kind }} As{{ .Kind }} { get; }
+        {{- end }}
+    }
+
+    /// <inheritdoc />
+    internal class {{ $classname }} : I{{ $classname }}
+    {
+        public {{ $classname }}Kind Kind { get; set; }
+        {{- range $definition.OneOfKinds }}
+
+        /// <inheritdoc />
+        public I{{ .Kind }} As{{ .Kind }} { get; set; }
+        {{- end }}
+
+        /// <summary>
+        /// The wire response for a "{{ $classname }}" carries no explicit
+        /// discriminator, so this checks each branch's own required fields
+        /// against the raw payload, in spec order, and deserializes into the
+        /// first branch whose fields are all present.
+        /// </summary>
+        public static {{ $classname }} FromJson(string json)
+        {
+            var fields = json.FromJson<Dictionary<string, object>>();
+            var result = new {{ $classname }}();
+            {{- range $i, $branch := $definition.OneOfKinds }}
+            {{- if eq $i 0 }}
+            if ({{ range $branch.Required }}fields.ContainsKey("{{ . }}") && {{ end }}true)
+            {{- else }}
+            else if ({{ range $branch.Required }}fields.ContainsKey("{{ . }}") && {{ end }}true)
+            {{- end }}
+            {
+                result.Kind = {{ $classname }}Kind.{{ $branch.Kind }};
+                result.As{{ $branch.Kind }} = json.FromJson<{{ $branch.Kind }}>();
+            }
+            {{- end }}
+            else
+            {
+                throw new InvalidOperationException("Unable to determine {{ $classname }} variant from response.");
+            }
+            return result;
+        }
+    }
+    {{- else }}
+    {{- range $propname, $property := $definition.Properties }}
+    {{- if $property.Enum }}
+
+    public enum {{ $classname }}{{ $propname | snakeCaseToPascalCase }}
+    {
+        {{- range $property.Enum }}
+        [TinyJson.JsonProperty("{{ . }}")]
+        {{ . | snakeCaseToPascalCase }},
+        {{- end }}
+    }
+    {{- end }}
+    {{- end }}
 
     /// <summary>
     /// {{ $definition.Description | stripNewlines }}
@@ -44,29 +114,12 @@ namespace Nakama
     {
         {{- range $propname, $property := $definition.Properties }}
         {{- $fieldname := $propname | snakeCaseToPascalCase }}
+        {{- $required := isRequired $definition.Required $propname }}
 
         /// <summary>
         /// {{ $property.Description }}
         /// </summary>
-        {{- if eq $property.Type "integer"}}
-        int {{ $fieldname }} { get; }
-        {{- else if eq $property.Type "boolean" }}
-        bool {{ $fieldname }} { get; }
-        {{- else if eq $property.Type "string"}}
-        string {{ $fieldname }} { get; }
-        {{- else if eq $property.Type "array"}}
-          {{- if eq $property.Items.Type "string"}}
-        List<string> {{ $fieldname }} { get; }
-          {{- else if eq $property.Items.Type "integer"}}
-        List<int> {{ $fieldname }} { get; }
-          {{- else if eq $property.Items.Type "boolean"}}
-        List<bool> {{ $fieldname }} { get; }
-          {{- else}}
-        List<I{{ $property.Items.Ref | cleanRef }}> {{ $fieldname }} { get; }
-          {{- end }}
-        {{- else }}
-        I{{ $property.Ref | cleanRef }} {{ $fieldname }} { get; }
-        {{- end }}
+        {{ csharpType $classname $propname $property $required }} {{ $fieldname }} { get; }
         {{- end }}
     }
 
@@ -75,35 +128,11 @@ namespace Nakama
     {
         {{- range $propname, $property := $definition.Properties }}
         {{- $fieldname := $propname | snakeCaseToPascalCase }}
+        {{- $required := isRequired $definition.Required $propname }}
 
         /// <inheritdoc />
-        {{- if eq $property.Type "integer"}}
-        [TinyJson.JsonProperty("{{ $propname }}")]
-        public int {{ $fieldname }} { get; set; }
-        {{- else if eq $property.Type "boolean" }}
-        [TinyJson.JsonProperty("{{ $propname }}")]
-        public bool {{ $fieldname }} { get; set; }
-        {{- else if eq $property.Type "string"}}
         [TinyJson.JsonProperty("{{ $propname }}")]
-        public string {{ $fieldname }} { get; set; }
-        {{- else if eq $property.Type "array"}}
-          {{- if eq $property.Items.Type "string"}}
-        [TinyJson.JsonProperty("{{ $propname }}")]
-        public List<string> {{ $fieldname }} { get; set; }
-          {{- else if eq $property.Items.Type "integer"}}
-        [TinyJson.JsonProperty("{{ $propname }}")]
-        public List<int> {{ $fieldname }} { get; set; }
-          {{- else if eq $property.Items.Type "boolean"}}
-        [TinyJson.JsonProperty("{{ $propname }}")]
-        public List<bool> {{ $fieldname }} { get; set; }
-          {{- else}}
-        [TinyJson.JsonProperty("{{ $propname }}")]
-        public List<I{{ $property.Items.Ref | cleanRef }}> {{ $fieldname }} { get; set; }
-          {{- end }}
-        {{- else }}
-        [TinyJson.JsonProperty("{{ $propname }}")]
-        public I{{ $property.Ref | cleanRef }} {{ $fieldname }} { get; set; }
-        {{- end }}
+        public {{ csharpType $classname $propname $property $required }} {{ $fieldname }} { get; set; }
         {{- end }}
 
         public override string ToString()
@@ -116,28 +145,156 @@ namespace Nakama
         }
     }
     {{- end }}
-/*
+    {{- end }}
+
     /// <summary>
     /// The low level client for the Nakama API.
     /// </summary>
     internal class ApiClient
     {
+        public readonly string Scheme;
+        public readonly string Host;
+        public readonly int Port;
+        public readonly string ServerKey;
+        public readonly System.Net.Http.HttpClient HttpClient;
+
+        public ApiClient(string scheme, string host, int port, string serverKey, System.Net.Http.HttpClient httpClient = null)
+        {
+            Scheme = scheme;
+            Host = host;
+            Port = port;
+            ServerKey = serverKey;
+            HttpClient = httpClient ?? new System.Net.Http.HttpClient();
+        }
         {{- range $url, $path := .Paths }}
         {{- range $method, $operation := $path}}
 
         /// <summary>
         /// {{ $operation.Summary | stripNewlines }}
         /// </summary>
-        public Task<> {{ $operation.OperationId | snakeCaseToPascalCase }}Async()
+        public async Task{{ if $operation.Responses.Ok.Schema.Ref }}<I{{ $operation.Responses.Ok.Schema.Ref | cleanRef }}>{{ end }} {{ $operation.OperationId | snakeCaseToPascalCase }}Async({{ methodParams $operation.Parameters }})
         {
+            var urlpath = "{{ $url | pathToUrl }}";
+            {{- range $operation.Parameters }}
+            {{- if eq .In "path" }}
+            urlpath = urlpath.Replace("{{ printf "{%s}" .Name }}", Uri.EscapeDataString({{ .Name | snakeCaseToPascalCase }}));
+            {{- end }}
+            {{- end }}
+
+            var queryParams = "";
+            {{- range $operation.Parameters }}
+            {{- if eq .In "query" }}
+            queryParams = string.Concat(queryParams, "{{ .Name }}=", Uri.EscapeDataString({{ .Name | snakeCaseToPascalCase }} ?? ""), "&");
+            {{- end }}
+            {{- end }}
+
+            var uri = new UriBuilder(Scheme, Host, Port, urlpath) { Query = queryParams }.Uri;
+            var request = new System.Net.Http.HttpRequestMessage(System.Net.Http.HttpMethod.{{ $method | title }}, uri);
+            {{- if requiresAuth $.Security $operation }}
+            request.Headers.Add("Authorization", "Bearer " + ServerKey);
+            {{- end }}
+            {{- range $operation.Parameters }}
+            {{- if eq .In "header" }}
+            request.Headers.Add("{{ .Name }}", {{ .Name | snakeCaseToPascalCase }});
+            {{- end }}
+            {{- end }}
+            {{- if $operation.Produces }}
+            request.Headers.Add("Accept", "{{ index $operation.Produces 0 }}");
+            {{- end }}
+            {{- if bodyParam $operation.Parameters }}
+            var content = {{ (bodyParam $operation.Parameters) | bodyParamName }}.ToJson();
+            request.Content = new System.Net.Http.StringContent(content, System.Text.Encoding.UTF8, "{{ if $operation.Consumes }}{{ index $operation.Consumes 0 }}{{ else }}application/json{{ end }}");
+            {{- end }}
+
+            var response = await HttpClient.SendAsync(request).ConfigureAwait(false);
+            response.EnsureSuccessStatusCode();
+            {{- if $operation.Responses.Ok.Schema.Ref }}
+            var json = await response.Content.ReadAsStringAsync().ConfigureAwait(false);
+            {{- if isOneOfRef $.Definitions $operation.Responses.Ok.Schema.Ref }}
+            return {{ $operation.Responses.Ok.Schema.Ref | cleanRef }}.FromJson(json);
+            {{- else }}
+            return json.FromJson<{{ $operation.Responses.Ok.Schema.Ref | cleanRef }}>();
+            {{- end }}
+            {{- end }}
         }
         {{- end }}
         {{- end }}
     }
-*/
 }
 `
 
+// items describes the "items" key of an array-typed schema or parameter.
+// Items is itself an "items" value, used when Type is "array" (i.e. nested
+// arrays, "type: array" of "type: array"); it is nil at the innermost level.
+type items struct {
+	Type  string
+	Ref   string `json:"$ref"`
+	Items *items
+}
+
+// parameter describes a single entry in an operation's "parameters" array.
+type parameter struct {
+	Name     string
+	In       string
+	Required bool
+	Type     string   // used with primitives
+	Items    items    // used with type "array"
+	Schema   struct { // used with http body
+		Type  string
+		Ref   string `json:"$ref"`
+		Items items  // used when the body is itself an array
+	}
+}
+
+// operation describes a single method (e.g. "get", "post") on a path. A nil
+// Security means the operation doesn't declare its own requirement and
+// inherits the document's top-level one; a non-nil-but-empty Security is an
+// explicit "no auth needed" override, per the Swagger/OpenAPI spec.
+type operation struct {
+	Summary     string
+	OperationId string
+	Consumes    []string
+	Produces    []string
+	Security    *[]map[string][]string
+	Responses   struct {
+		Ok struct {
+			Schema struct {
+				Ref string `json:"$ref"`
+			}
+		} `json:"200"`
+	}
+	Parameters []parameter
+}
+
+// property describes a single field of a definition.
+type property struct {
+	Type        string
+	Ref         string `json:"$ref"` // used with object
+	Items       items  // used with type "array"
+	Format      string // e.g. "int64", "date-time", "byte"
+	Enum        []string
+	Description string
+}
+
+// definition describes a single entry in the spec's "definitions" map. A
+// definition built from a "oneOf"/"anyOf" schema has no properties of its
+// own; OneOfKinds is populated instead and the template emits a discriminated
+// wrapper for it rather than a plain data class.
+type definition struct {
+	Properties  map[string]property
+	Description string
+	Required    []string
+	Example     json.RawMessage
+	OneOfKinds  []oneOfBranch `json:"-"`
+}
+
+// openapiSchema is the subset of a Swagger 2.0 document this generator understands.
+type openapiSchema struct {
+	Paths       map[string]map[string]operation
+	Definitions map[string]definition
+	Security    []map[string][]string
+}
+
 func convertRefToClassName(input string) (className string) {
 	cleanRef := strings.TrimLeft(input, "#/definitions/")
 	className = strings.Title(cleanRef)
@@ -170,69 +327,250 @@ func stripNewlines(input string) (output string) {
 	return
 }
 
+// pathToUrl converts an OpenAPI path template (e.g. "/v2/account/{id}") into
+// the literal string emitted into the generated C#. Path parameters are left
+// as "{name}" placeholders and substituted at call time with string.Replace.
+func pathToUrl(input string) (output string) {
+	output = strings.Replace(input, `"`, `\"`, -1)
+	return
+}
+
+// bodyParam returns the single "in: body" parameter of an operation, if any.
+// It is used both to decide whether a request needs a body and to render the
+// method's C# parameter list.
+func bodyParam(parameters []parameter) *parameter {
+	for i := range parameters {
+		if parameters[i].In == "body" {
+			return &parameters[i]
+		}
+	}
+	return nil
+}
+
+// bodyParamDecl renders the C# parameter declaration for a body parameter,
+// e.g. "IAccount account". Returns "" when there is no body parameter so it
+// can be used directly inside a method signature.
+func bodyParamDecl(p *parameter) string {
+	if p == nil {
+		return ""
+	}
+	return fmt.Sprintf("I%s %s", convertRefToClassName(p.Schema.Ref), snakeCaseToPascalCase(p.Name))
+}
+
+// bodyParamName returns the local variable name used to reference a body
+// parameter inside a generated method body, e.g. "account".
+func bodyParamName(p *parameter) string {
+	if p == nil {
+		return ""
+	}
+	return snakeCaseToPascalCase(p.Name)
+}
+
+// methodParams renders an operation's full C# parameter list: one "string
+// Name" per path/query/header parameter, comma-separated, followed by the
+// body parameter's declaration (if any). Built as a single string rather
+// than joined inline in the template so there's never a dangling separator
+// when an operation has no body parameter.
+func methodParams(parameters []parameter) string {
+	var parts []string
+	for _, p := range parameters {
+		if p.In == "body" {
+			continue
+		}
+		parts = append(parts, "string "+snakeCaseToPascalCase(p.Name))
+	}
+	if decl := bodyParamDecl(bodyParam(parameters)); len(decl) > 0 {
+		parts = append(parts, decl)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// requiresAuth reports whether a generated method should attach the bearer
+// token: an operation's own "security" overrides the document's top-level
+// one when present, including an explicit empty list meaning "no auth".
+func requiresAuth(documentSecurity []map[string][]string, op operation) bool {
+	security := documentSecurity
+	if op.Security != nil {
+		security = *op.Security
+	}
+	return len(security) > 0
+}
+
+// isOneOfRef reports whether ref (e.g. "#/definitions/LinkAccount") points to
+// a definition built from "oneOf"/"anyOf", i.e. one the template emits a
+// discriminated wrapper for rather than a plain data class. Responses
+// typed with such a ref are deserialized through that wrapper's own
+// FromJson, not the generic one, since the wire payload has no field to
+// drive TinyJson's normal attribute-based mapping.
+func isOneOfRef(defs map[string]definition, ref string) bool {
+	def, ok := defs[refName(ref)]
+	return ok && len(def.OneOfKinds) > 0
+}
+
+// isRequired reports whether propname is listed in a definition's "required"
+// array.
+func isRequired(required []string, propname string) bool {
+	for _, name := range required {
+		if name == propname {
+			return true
+		}
+	}
+	return false
+}
+
+// arrayElementType renders the C# element type of a "type: array" property,
+// e.g. "string", "int", "IAccount", or (for an array whose items are
+// themselves an array) "List<int>".
+func arrayElementType(i items) string {
+	switch i.Type {
+	case "string":
+		return "string"
+	case "integer":
+		return "int"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "List<" + arrayElementType(*i.Items) + ">"
+	default:
+		return "I" + convertRefToClassName(i.Ref)
+	}
+}
+
+// csharpType renders the C# property type for propname on classname,
+// including the generated enum name when the property declares an "enum",
+// format-aware mappings ("int64" -> long, "date-time" -> DateTime, "byte" ->
+// byte[]), and a trailing "?" when the property isn't in its definition's
+// "required" array. propname is the raw (snake_case) property name so it can
+// be matched against the "required" array as written in the spec.
+func csharpType(classname, propname string, p property, required bool) string {
+	nullable := ""
+	if !required {
+		nullable = "?"
+	}
+
+	switch {
+	case len(p.Enum) > 0:
+		return classname + snakeCaseToPascalCase(propname) + nullable
+	case p.Type == "integer":
+		if p.Format == "int64" {
+			return "long" + nullable
+		}
+		return "int" + nullable
+	case p.Type == "boolean":
+		return "bool" + nullable
+	case p.Type == "string":
+		switch p.Format {
+		case "date-time":
+			return "DateTime" + nullable
+		case "byte":
+			return "byte[]"
+		default:
+			return "string"
+		}
+	case p.Type == "array":
+		return "List<" + arrayElementType(p.Items) + ">"
+	default:
+		return "I" + convertRefToClassName(p.Ref)
+	}
+}
+
+func usage() {
+	fmt.Println("openapi-gen <generate|lint> [flags] input")
+	fmt.Println()
+	fmt.Println("generate:")
+	generateFlags.PrintDefaults()
+	fmt.Println()
+	fmt.Println("lint:")
+	lintFlags.PrintDefaults()
+}
+
+var (
+	generateFlags    = flag.NewFlagSet("generate", flag.ExitOnError)
+	generateOutput   = generateFlags.String("output", "", "The output for generated code.")
+	generateForce    = generateFlags.Bool("force", false, "Generate code even if the input fails lint.")
+	generateEmitTest = generateFlags.String("emit-tests", "", "Also write an xUnit {Class}Fixture.cs per definition into this directory.")
+
+	lintFlags = flag.NewFlagSet("lint", flag.ExitOnError)
+)
+
 func main() {
-	// Argument flags
-	var output = flag.String("output", "", "The output for generated code.")
-	flag.Parse()
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
 
-	inputs := flag.Args()
+	switch os.Args[1] {
+	case "generate":
+		generateFlags.Parse(os.Args[2:])
+		runGenerate(generateFlags.Args(), *generateOutput, *generateForce, *generateEmitTest)
+	case "lint":
+		lintFlags.Parse(os.Args[2:])
+		runLint(lintFlags.Args())
+	default:
+		usage()
+		os.Exit(1)
+	}
+}
+
+// runLint validates inputs[0] against the Swagger/OpenAPI meta-schema and
+// prints every violation it finds.
+func runLint(inputs []string) {
 	if len(inputs) < 1 {
-		fmt.Printf("No input file found: %s\n\n", inputs)
-		fmt.Println("openapi-gen [flags] inputs...")
-		flag.PrintDefaults()
-		return
+		fmt.Println("No input file given.")
+		os.Exit(1)
 	}
 
-	input := inputs[0]
-	content, err := ioutil.ReadFile(input)
+	violations, err := lintSpec(inputs[0])
 	if err != nil {
-		fmt.Printf("Unable to read file: %s\n", err)
-		return
+		fmt.Printf("Unable to lint %s : %s\n", inputs[0], err)
+		os.Exit(1)
+	}
+	if len(violations) > 0 {
+		for _, v := range violations {
+			fmt.Println(v)
+		}
+		os.Exit(1)
 	}
+	fmt.Printf("%s: OK\n", inputs[0])
+}
 
-	var schema struct {
-		Paths map[string]map[string]struct {
-			Summary     string
-			OperationId string
-			Responses   struct {
-				Ok struct {
-					Schema struct {
-						Ref string `json:"$ref"`
-					}
-				} `json:"200"`
-			}
-			Parameters []struct {
-				Name     string
-				In       string
-				Required bool
-				Type     string   // used with primitives
-				Items    struct { // used with type "array"
-					Type string
-				}
-				Schema struct { // used with http body
-					Type string
-					Ref  string `json:"$ref"`
-				}
-			}
+// runGenerate lints inputs[0] (unless force is set) and, if it passes, emits
+// the generated C# to output (or stdout when output is empty).
+func runGenerate(inputs []string, output string, force bool, emitTestsDir string) {
+	if len(inputs) < 1 {
+		fmt.Printf("No input file found: %s\n\n", inputs)
+		usage()
+		os.Exit(1)
+	}
+
+	input := inputs[0]
+
+	if !force {
+		violations, err := lintSpec(input)
+		if err != nil {
+			fmt.Printf("Unable to lint %s : %s\n", input, err)
+			os.Exit(1)
 		}
-		Definitions map[string]struct {
-			Properties map[string]struct {
-				Type  string
-				Ref   string   `json:"$ref"` // used with object
-				Items struct { // used with type "array"
-					Type string
-					Ref  string `json:"$ref"`
-				}
-				Format      string // used with type "boolean"
-				Description string
+		if len(violations) > 0 {
+			fmt.Printf("%s failed lint, refusing to generate (use -force to override):\n", input)
+			for _, v := range violations {
+				fmt.Println(v)
 			}
-			Description string
+			os.Exit(1)
 		}
 	}
 
-	if err := json.Unmarshal(content, &schema); err != nil {
-		fmt.Printf("Unable to decode input %s : %s\n", input, err)
-		return
+	schema, err := loadSpec(input)
+	if err != nil {
+		fmt.Printf("Unable to load input %s : %s\n", input, err)
+		os.Exit(1)
+	}
+
+	if len(emitTestsDir) > 0 {
+		if err := emitTests(schema, emitTestsDir); err != nil {
+			fmt.Printf("Unable to emit tests: %s\n", err)
+			os.Exit(1)
+		}
 	}
 
 	fmap := template.FuncMap{
@@ -241,22 +579,31 @@ func main() {
 		"stripNewlines":         stripNewlines,
 		"title":                 strings.Title,
 		"uppercase":             strings.ToUpper,
+		"pathToUrl":             pathToUrl,
+		"bodyParam":             bodyParam,
+		"bodyParamDecl":         bodyParamDecl,
+		"bodyParamName":         bodyParamName,
+		"isRequired":            isRequired,
+		"csharpType":            csharpType,
+		"methodParams":          methodParams,
+		"requiresAuth":          requiresAuth,
+		"isOneOfRef":            isOneOfRef,
 	}
 	tmpl, err := template.New(input).Funcs(fmap).Parse(codeTemplate)
 	if err != nil {
 		fmt.Printf("Template parse error: %s\n", err)
-		return
+		os.Exit(1)
 	}
 
-	if len(*output) < 1 {
+	if len(output) < 1 {
 		tmpl.Execute(os.Stdout, schema)
 		return
 	}
 
-	f, err := os.Create(*output)
+	f, err := os.Create(output)
 	if err != nil {
 		fmt.Printf("Unable to create file: %s\n", err)
-		return
+		os.Exit(1)
 	}
 	defer f.Close()
 
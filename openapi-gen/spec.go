@@ -0,0 +1,381 @@
+// Copyright 2018 The Nakama Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// oneOfBranch describes a single alternative of a "oneOf"/"anyOf" schema. The
+// generator emits these as a discriminated wrapper: one enum member and one
+// nullable "AsX" property per branch. Since the wire payload carries no
+// discriminator field of its own, Required is the branch's own "required"
+// list, used at runtime to tell which branch a response body actually is.
+type oneOfBranch struct {
+	Kind     string   // PascalCase enum member, e.g. "EmailAccount"
+	Ref      string   // "#/definitions/EmailAccount"
+	Required []string // the branch definition's own "required" properties
+}
+
+// specLoader resolves an OpenAPI/Swagger document on disk into the
+// openapiSchema this generator's template understands: a single Swagger 2.0
+// shaped document with every external $ref inlined and every "allOf" merged
+// away. Internal "$ref"s into "definitions" are left alone, since those are
+// what the template turns into "I{Class}" interface references.
+type specLoader struct {
+	baseDir string
+	cache   map[string]map[string]interface{} // absolute path -> parsed document
+	oneOfs  map[string][]oneOfBranch          // definition name -> its oneOf/anyOf branches
+}
+
+// loadSpec reads the OpenAPI/Swagger document at path and returns the
+// resolved schema ready to feed into the code template.
+func loadSpec(path string) (openapiSchema, error) {
+	var schema openapiSchema
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return schema, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return schema, fmt.Errorf("unable to decode input %s : %s", path, err)
+	}
+
+	loader := &specLoader{
+		baseDir: filepath.Dir(path),
+		cache:   map[string]map[string]interface{}{},
+		oneOfs:  map[string][]oneOfBranch{},
+	}
+
+	if isOpenAPI3(raw) {
+		normalizeOpenAPI3(raw)
+	}
+
+	raw = loader.resolveRefs(raw).(map[string]interface{})
+	loader.cache["__root__"] = raw
+
+	if definitions, ok := raw["definitions"].(map[string]interface{}); ok {
+		for name, def := range definitions {
+			definitions[name] = loader.flattenComposition(name, def)
+		}
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return schema, err
+	}
+
+	if err := json.Unmarshal(normalized, &schema); err != nil {
+		return schema, fmt.Errorf("unable to decode normalized input %s : %s", path, err)
+	}
+
+	for name, branches := range loader.oneOfs {
+		def := schema.Definitions[name]
+		def.OneOfKinds = branches
+		schema.Definitions[name] = def
+	}
+
+	return schema, nil
+}
+
+// isOpenAPI3 reports whether the document is an OpenAPI 3.x document, as
+// opposed to a Swagger 2.0 one.
+func isOpenAPI3(raw map[string]interface{}) bool {
+	version, ok := raw["openapi"].(string)
+	return ok && strings.HasPrefix(version, "3")
+}
+
+// normalizeOpenAPI3 rewrites an OpenAPI 3.x document in place so the rest of
+// the pipeline can treat it like Swagger 2.0: "components/schemas" becomes
+// "definitions", a "requestBody" becomes a synthetic "body" parameter, and a
+// "200" response's "content" schema becomes the bare "schema" field the
+// template expects.
+func normalizeOpenAPI3(raw map[string]interface{}) {
+	if components, ok := raw["components"].(map[string]interface{}); ok {
+		if schemas, ok := components["schemas"]; ok {
+			raw["definitions"] = schemas
+		}
+	}
+	rewriteComponentRefs(raw)
+
+	paths, ok := raw["paths"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	for _, rawPath := range paths {
+		path, ok := rawPath.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, rawOperation := range path {
+			op, ok := rawOperation.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if body, ok := op["requestBody"].(map[string]interface{}); ok {
+				if schema := mediaTypeSchema(body["content"]); schema != nil {
+					parameters, _ := op["parameters"].([]interface{})
+					parameters = append(parameters, map[string]interface{}{
+						"name":     "body",
+						"in":       "body",
+						"required": body["required"],
+						"schema":   schema,
+					})
+					op["parameters"] = parameters
+				}
+			}
+
+			if responses, ok := op["responses"].(map[string]interface{}); ok {
+				if ok200, ok := responses["200"].(map[string]interface{}); ok {
+					if schema := mediaTypeSchema(ok200["content"]); schema != nil {
+						ok200["schema"] = schema
+					}
+				}
+			}
+		}
+	}
+}
+
+// rewriteComponentRefs walks node in place and rewrites every "#/components/
+// schemas/X" $ref string to "#/definitions/X", matching where
+// normalizeOpenAPI3 actually puts those definitions. Internal refs are the
+// only kind resolveRefs leaves alone (they become "I{Class}" interface
+// references), so if they still pointed at "components/schemas" the
+// generator would treat the whole ref string as a class name.
+func rewriteComponentRefs(node interface{}) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := n["$ref"].(string); ok {
+			n["$ref"] = strings.Replace(ref, "#/components/schemas/", "#/definitions/", 1)
+		}
+		for _, v := range n {
+			rewriteComponentRefs(v)
+		}
+	case []interface{}:
+		for _, v := range n {
+			rewriteComponentRefs(v)
+		}
+	}
+}
+
+// mediaTypeSchema pulls the "application/json" schema out of an OpenAPI 3
+// "content" map, if present.
+func mediaTypeSchema(content interface{}) interface{} {
+	media, ok := content.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	json, ok := media["application/json"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return json["schema"]
+}
+
+// resolveRefs walks the document and replaces every external $ref (one that
+// points into another file, e.g. "common.json#/definitions/Error") with the
+// node it points to, loading and caching that file as needed. Internal refs
+// such as "#/definitions/Account" are left untouched, since those become
+// "I{Class}" interface references rather than inlined schemas.
+func (l *specLoader) resolveRefs(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if ref, ok := n["$ref"].(string); ok && !strings.HasPrefix(ref, "#/") {
+			return l.resolveRefs(l.loadExternalRef(ref))
+		}
+		for key, value := range n {
+			n[key] = l.resolveRefs(value)
+		}
+		return n
+	case []interface{}:
+		for i, value := range n {
+			n[i] = l.resolveRefs(value)
+		}
+		return n
+	default:
+		return node
+	}
+}
+
+// loadExternalRef loads "file.json#/pointer/into/it" relative to the spec's
+// directory and returns the node the pointer addresses.
+func (l *specLoader) loadExternalRef(ref string) interface{} {
+	parts := strings.SplitN(ref, "#", 2)
+	file := filepath.Join(l.baseDir, parts[0])
+
+	doc, ok := l.cache[file]
+	if !ok {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return map[string]interface{}{}
+		}
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return map[string]interface{}{}
+		}
+		l.cache[file] = doc
+	}
+
+	if len(parts) < 2 {
+		return doc
+	}
+	return resolvePointer(doc, parts[1])
+}
+
+// resolvePointer walks a JSON Pointer (e.g. "/definitions/Error") inside doc.
+func resolvePointer(doc map[string]interface{}, pointer string) interface{} {
+	var current interface{} = doc
+	for _, segment := range strings.Split(strings.Trim(pointer, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[segment]
+	}
+	return current
+}
+
+// flattenComposition resolves "allOf" by merging every branch's properties
+// into a single definition, and records "oneOf"/"anyOf" branches so the
+// template can emit a discriminated wrapper for name instead.
+func (l *specLoader) flattenComposition(name string, raw interface{}) interface{} {
+	def, ok := raw.(map[string]interface{})
+	if !ok {
+		return raw
+	}
+
+	if allOf, ok := def["allOf"].([]interface{}); ok {
+		merged := map[string]interface{}{
+			"description": def["description"],
+			"properties":  map[string]interface{}{},
+		}
+
+		required := map[string]bool{}
+		for _, r := range toStringSlice(def["required"]) {
+			required[r] = true
+		}
+		example := def["example"]
+
+		for _, branch := range allOf {
+			branchDef, ok := branch.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if ref, ok := branchDef["$ref"].(string); ok {
+				branchDef, _ = l.localDefinition(ref).(map[string]interface{})
+			}
+			if branchDef == nil {
+				continue
+			}
+			if props, ok := branchDef["properties"].(map[string]interface{}); ok {
+				for propName, prop := range props {
+					merged["properties"].(map[string]interface{})[propName] = prop
+				}
+			}
+			for _, r := range toStringSlice(branchDef["required"]) {
+				required[r] = true
+			}
+			if example == nil {
+				example = branchDef["example"]
+			}
+			if desc, ok := branchDef["description"].(string); ok && merged["description"] == nil {
+				merged["description"] = desc
+			}
+		}
+
+		// Only set "required"/"example" when there's something real to put
+		// there: leaving either as a literal Go nil would marshal to JSON
+		// null, which downstream code (Example is a json.RawMessage) can't
+		// tell apart from "no example was given".
+		if len(required) > 0 {
+			names := make([]string, 0, len(required))
+			for r := range required {
+				names = append(names, r)
+			}
+			sort.Strings(names)
+			merged["required"] = names
+		}
+		if example != nil {
+			merged["example"] = example
+		}
+
+		return merged
+	}
+
+	branches := def["oneOf"]
+	if branches == nil {
+		branches = def["anyOf"]
+	}
+	if refs, ok := branches.([]interface{}); ok {
+		var kinds []oneOfBranch
+		for _, branch := range refs {
+			branchDef, ok := branch.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			ref, _ := branchDef["$ref"].(string)
+			resolved, _ := l.localDefinition(ref).(map[string]interface{})
+			kinds = append(kinds, oneOfBranch{
+				Kind:     convertRefToClassName(ref),
+				Ref:      ref,
+				Required: toStringSlice(resolved["required"]),
+			})
+		}
+		l.oneOfs[name] = kinds
+		delete(def, "oneOf")
+		delete(def, "anyOf")
+		if def["properties"] == nil {
+			def["properties"] = map[string]interface{}{}
+		}
+	}
+
+	return def
+}
+
+// toStringSlice converts a decoded JSON array (whose element type is opaque
+// interface{}) into a []string, skipping any element that isn't a string.
+func toStringSlice(v interface{}) []string {
+	raw, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	var out []string
+	for _, elem := range raw {
+		if s, ok := elem.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// localDefinition looks up an internal "#/definitions/X" ref against the
+// fully $ref-resolved document, for use while flattening "allOf" branches.
+func (l *specLoader) localDefinition(ref string) interface{} {
+	doc, ok := l.cache["__root__"]
+	if !ok {
+		return nil
+	}
+	return resolvePointer(doc, strings.TrimPrefix(ref, "#"))
+}
@@ -0,0 +1,401 @@
+// Copyright 2018 The Nakama Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const fixtureTemplate = `/* Code generated by openapi-gen/main.go. DO NOT EDIT. */
+
+using System;
+using System.Collections.Generic;
+using Xunit;
+
+namespace Nakama.Tests
+{
+    internal static class {{ .Classname }}Fixture
+    {
+        public static I{{ .Classname }} Create()
+        {
+            return new {{ .Classname }}
+            {
+                {{- range .Assignments }}
+                {{ .Field }} = {{ .Expr }},
+                {{- end }}
+            };
+        }
+    }
+
+    public class {{ .Classname }}FixtureTests
+    {
+        [Fact]
+        public void SerializesToExpectedJson()
+        {
+            var fixture = {{ .Classname }}Fixture.Create();
+            var json = fixture.ToJson();
+            Assert.Equal(@"{{ .ExpectedJson }}", json);
+        }
+    }
+}
+`
+
+// fixtureAssignment is one "Field = Expr" line in a generated fixture
+// factory, e.g. {Field: "UserId", Expr: `"sample"`}.
+type fixtureAssignment struct {
+	Field string
+	Expr  string
+}
+
+// fixtureData is the data handed to fixtureTemplate for a single definition.
+type fixtureData struct {
+	Classname    string
+	Assignments  []fixtureAssignment
+	ExpectedJson string
+}
+
+// emitTests writes a "{Class}Fixture.cs" file into dir for every definition
+// in schema.Definitions: a factory producing a fully populated instance, and
+// an xUnit fact asserting that TinyJson serializes it to the matching JSON.
+// Both are built from the same per-property values, so they can never
+// diverge: where the definition's own "example" gives a usable value for a
+// property, that value drives both the fixture assignment and the expected
+// JSON; everywhere else, a deterministic schema-derived sample value does.
+func emitTests(schema openapiSchema, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmpl, err := template.New("fixture").Parse(fixtureTemplate)
+	if err != nil {
+		return err
+	}
+
+	for name, def := range schema.Definitions {
+		if len(def.OneOfKinds) > 0 {
+			// TinyJson has no concept of a discriminated union, so there is
+			// no deterministic wire shape to assert a round-trip against.
+			continue
+		}
+
+		classname := convertRefToClassName("#/definitions/" + name)
+		assignments, values, err := definitionFixture(schema.Definitions, classname, def)
+		if err != nil {
+			return err
+		}
+		expected, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+
+		data := fixtureData{
+			Classname:    classname,
+			Assignments:  assignments,
+			ExpectedJson: strings.ReplaceAll(string(expected), `"`, `""`),
+		}
+
+		f, err := os.Create(filepath.Join(dir, classname+"Fixture.cs"))
+		if err != nil {
+			return err
+		}
+		err = tmpl.Execute(f, data)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// definitionFixture renders one "Field = Expr" assignment per property of a
+// plain (non-oneOf) definition alongside the JSON value that same assignment
+// produces, ordered by field name for stable output. A property present in
+// the definition's own "example" drives both; everything else falls back to
+// a deterministic schema-derived sample.
+func definitionFixture(defs map[string]definition, classname string, def definition) ([]fixtureAssignment, map[string]interface{}, error) {
+	example := exampleObject(def)
+
+	var assignments []fixtureAssignment
+	values := map[string]interface{}{}
+	for propname, p := range def.Properties {
+		if raw, ok := example[propname]; ok {
+			if expr, ok := overrideExpr(classname, propname, p, raw); ok {
+				assignments = append(assignments, fixtureAssignment{Field: snakeCaseToPascalCase(propname), Expr: expr})
+				values[propname] = raw
+				continue
+			}
+		}
+
+		v, err := samplePropertyValue(defs, p, map[string]bool{})
+		if err != nil {
+			return nil, nil, err
+		}
+		assignments = append(assignments, fixtureAssignment{
+			Field: snakeCaseToPascalCase(propname),
+			Expr:  sampleExpr(classname, propname, p),
+		})
+		values[propname] = v
+	}
+	sort.Slice(assignments, func(i, j int) bool { return assignments[i].Field < assignments[j].Field })
+	return assignments, values, nil
+}
+
+// exampleObject decodes a definition's "example" into a property-name-keyed
+// map, or returns nil if there is none (or it isn't a JSON object) so callers
+// can fall back to schema-derived samples for every property.
+func exampleObject(def definition) map[string]interface{} {
+	if len(def.Example) == 0 {
+		return nil
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(def.Example, &obj); err != nil {
+		return nil
+	}
+	return obj
+}
+
+// overrideExpr renders the C# literal for a property's real example value,
+// or returns ok=false when the value's shape doesn't match the property
+// (or is a nested object/ref, which isn't supported) so the caller can fall
+// back to a schema-derived sample instead.
+func overrideExpr(classname, propname string, p property, raw interface{}) (expr string, ok bool) {
+	switch {
+	case len(p.Enum) > 0:
+		s, isString := raw.(string)
+		if !isString {
+			return "", false
+		}
+		for _, e := range p.Enum {
+			if e == s {
+				return fmt.Sprintf("%s%s.%s", classname, snakeCaseToPascalCase(propname), snakeCaseToPascalCase(e)), true
+			}
+		}
+		return "", false
+	case p.Type == "integer":
+		n, isNumber := raw.(float64)
+		if !isNumber {
+			return "", false
+		}
+		return strconv.FormatInt(int64(n), 10), true
+	case p.Type == "boolean":
+		b, isBool := raw.(bool)
+		if !isBool {
+			return "", false
+		}
+		return strconv.FormatBool(b), true
+	case p.Type == "string":
+		s, isString := raw.(string)
+		if !isString {
+			return "", false
+		}
+		switch p.Format {
+		case "date-time":
+			return fmt.Sprintf("DateTime.Parse(%q, null, System.Globalization.DateTimeStyles.RoundtripKind)", s), true
+		case "byte":
+			return fmt.Sprintf("Convert.FromBase64String(%q)", s), true
+		default:
+			return fmt.Sprintf("%q", s), true
+		}
+	case p.Type == "array":
+		elements, isArray := raw.([]interface{})
+		if !isArray {
+			return "", false
+		}
+		exprs := make([]string, len(elements))
+		for i, elem := range elements {
+			elemExpr, ok := arrayElementOverrideExpr(p.Items, elem)
+			if !ok {
+				return "", false
+			}
+			exprs[i] = elemExpr
+		}
+		return fmt.Sprintf("new List<%s> { %s }", arrayElementType(p.Items), strings.Join(exprs, ", ")), true
+	default:
+		return "", false // a nested object/ref has its own fixture; not overridden here
+	}
+}
+
+// arrayElementOverrideExpr renders a single example array element as a C#
+// literal, or returns ok=false for shapes it doesn't support (see
+// overrideExpr).
+func arrayElementOverrideExpr(i items, raw interface{}) (string, bool) {
+	switch i.Type {
+	case "string":
+		s, ok := raw.(string)
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("%q", s), true
+	case "integer":
+		n, ok := raw.(float64)
+		if !ok {
+			return "", false
+		}
+		return strconv.FormatInt(int64(n), 10), true
+	case "boolean":
+		b, ok := raw.(bool)
+		if !ok {
+			return "", false
+		}
+		return strconv.FormatBool(b), true
+	case "array":
+		elements, ok := raw.([]interface{})
+		if !ok {
+			return "", false
+		}
+		exprs := make([]string, len(elements))
+		for idx, elem := range elements {
+			elemExpr, ok := arrayElementOverrideExpr(*i.Items, elem)
+			if !ok {
+				return "", false
+			}
+			exprs[idx] = elemExpr
+		}
+		return fmt.Sprintf("new List<%s> { %s }", arrayElementType(*i.Items), strings.Join(exprs, ", ")), true
+	default:
+		return "", false
+	}
+}
+
+// sampleExpr renders the C# literal assigned to a property in a generated
+// fixture: deterministic sample values derived from the property's type
+// (strings -> "sample", ints -> 42, arrays -> a single-element list, refs ->
+// a recursive call to that type's own fixture).
+func sampleExpr(classname, propname string, p property) string {
+	switch {
+	case len(p.Enum) > 0:
+		return fmt.Sprintf("%s%s.%s", classname, snakeCaseToPascalCase(propname), snakeCaseToPascalCase(p.Enum[0]))
+	case p.Type == "integer":
+		return "42"
+	case p.Type == "boolean":
+		return "true"
+	case p.Type == "string":
+		switch p.Format {
+		case "date-time":
+			return "new DateTime(2020, 1, 1, 0, 0, 0, DateTimeKind.Utc)"
+		case "byte":
+			return "new byte[] { 1, 2, 3 }"
+		default:
+			return `"sample"`
+		}
+	case p.Type == "array":
+		return fmt.Sprintf("new List<%s> { %s }", arrayElementType(p.Items), arrayElementSampleExpr(p.Items))
+	default:
+		return convertRefToClassName(p.Ref) + "Fixture.Create()"
+	}
+}
+
+// arrayElementSampleExpr renders the single sample element of a "type:
+// array" property's fixture list.
+func arrayElementSampleExpr(i items) string {
+	switch i.Type {
+	case "string":
+		return `"sample"`
+	case "integer":
+		return "42"
+	case "boolean":
+		return "true"
+	case "array":
+		return fmt.Sprintf("new List<%s> { %s }", arrayElementType(*i.Items), arrayElementSampleExpr(*i.Items))
+	default:
+		return convertRefToClassName(i.Ref) + "Fixture.Create()"
+	}
+}
+
+// sampleValue builds the deterministic schema-derived value for defname,
+// recursing through ref properties the same way sampleExpr's nested
+// "Fixture.Create()" calls do. seen guards against infinite recursion
+// through self-referential definitions.
+func sampleValue(defs map[string]definition, defname string, seen map[string]bool) (interface{}, error) {
+	if seen[defname] {
+		return map[string]interface{}{}, nil
+	}
+	seen[defname] = true
+
+	def, ok := defs[defname]
+	if !ok {
+		return nil, fmt.Errorf("unknown definition %q", defname)
+	}
+
+	out := map[string]interface{}{}
+	for propname, p := range def.Properties {
+		v, err := samplePropertyValue(defs, p, seen)
+		if err != nil {
+			return nil, err
+		}
+		out[propname] = v
+	}
+	return out, nil
+}
+
+func samplePropertyValue(defs map[string]definition, p property, seen map[string]bool) (interface{}, error) {
+	switch {
+	case len(p.Enum) > 0:
+		return p.Enum[0], nil
+	case p.Type == "integer":
+		return 42, nil
+	case p.Type == "boolean":
+		return true, nil
+	case p.Type == "string":
+		switch p.Format {
+		case "date-time":
+			return "2020-01-01T00:00:00Z", nil
+		case "byte":
+			return "AQID", nil // base64("\x01\x02\x03"), matching sampleExpr's byte[]{1,2,3}
+		default:
+			return "sample", nil
+		}
+	case p.Type == "array":
+		elem, err := arrayElementSampleValue(defs, p.Items, seen)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{elem}, nil
+	default:
+		return sampleValue(defs, refName(p.Ref), seen)
+	}
+}
+
+// refName extracts the definition name a "#/definitions/X" ref points to, as
+// it appears as a key in openapiSchema.Definitions.
+func refName(ref string) string {
+	return strings.TrimPrefix(ref, "#/definitions/")
+}
+
+func arrayElementSampleValue(defs map[string]definition, i items, seen map[string]bool) (interface{}, error) {
+	switch i.Type {
+	case "string":
+		return "sample", nil
+	case "integer":
+		return 42, nil
+	case "boolean":
+		return true, nil
+	case "array":
+		elem, err := arrayElementSampleValue(defs, *i.Items, seen)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{elem}, nil
+	default:
+		return sampleValue(defs, refName(i.Ref), seen)
+	}
+}